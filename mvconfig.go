@@ -17,6 +17,38 @@ The name of the field will be used as the ENVIRONMENT variable - however - you c
 You can also pass in a PREFIX to be used on all the variables in the struct.  The PREFIX will be added the front of the
 names with an '_'.  So if PREFIX = ZITOBOX - then the var names will be ZITOBOX_NAME
 
+As well as string, int, uint, bool and float fields, you can use []string, []int, map[string]string, map[string]int
+and time.Duration. Slices and maps are comma-separated by default (override with `sep:";"`), and map entries are
+`key:value` pairs, e.g. `MYAPP_COLORCODES="red:1,green:2"`.
+
+For your own types, implement the Decoder interface (`Decode(value string) error`) or encoding.TextUnmarshaler on the
+field type and mvconfig will use it instead of the builtin kinds - handy for things like net.IP, url.URL or an enum.
+
+Struct fields are descended into automatically, with the prefix growing by the field's name (or its `mvenv` tag) at
+each level. So a `Config{ DB DBConfig }` with a `Host` field on DBConfig, loaded with PREFIX = APP, looks up
+APP_DB_Host - names are joined as written and are not upper-cased; with `mvenv:"HOST"` on Host it looks up
+APP_DB_HOST.
+
+A value can reference other environment variables with `${OTHER_VAR}` or `$OTHER_VAR`, resolved via os.LookupEnv, by
+tagging the field `expand:"true"`:
+`TempFolder string \`mvenv:"TEMP_FOLDER" def:"${HOME}/tmp" expand:"true"\``
+Use LoadVariablesWithOptions with LoadOptions.ErrorOnUndefinedExpand if an undefined reference should be an error
+rather than expanding to "".
+
+Call Usage(&envs, os.Stdout) (or Usagef with a PREFIX) to print a table of every variable the struct expects, its
+type, default and whether it's required - good for a `-help-env` flag. Add a `desc:"..."` tag to document a field
+in that output.
+
+LoadVariables* no longer stops at the first bad field - it keeps going and returns every missing-critical and
+parse error together as an *AggregateError, whose Errors() []error lets you see them all at once. Each individual
+error wraps ErrMissingRequired or ErrParse, so errors.Is(err, mvconfig.ErrParse) still works whether err is the
+aggregate or a single error.
+
+Under the hood every lookup goes through a chain of Sources (EnvSource, PropsSource, JSONSource, MapSource, or
+your own Lookup(name string) (string, bool) implementation). The LoadVariables* helpers above build the classic
+[EnvSource, PropsSource] chain; call LoadVariablesFromSources(&cfg, sources...) directly to pick your own sources
+and precedence, e.g. to add a JSONSource or a Vault client ahead of the environment.
+
 
 Usage
 -----
@@ -46,47 +78,295 @@ fmt.Println(envs)
 package mvconfig
 
 import (
+	"encoding"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/magiconair/properties"
+	"io"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Decoder lets a type take over its own decoding from a string, for cases the
+// builtin kind switch doesn't cover - net.IP, url.URL, []byte, enum types and
+// so on. Decoder is tried first, then encoding.TextUnmarshaler, then the
+// builtin kinds.
+type Decoder interface {
+	Decode(value string) error
+}
+
+// Sentinel errors that every individual field error wraps, so callers can
+// filter an AggregateError's Errors() by category with errors.Is, e.g.
+// errors.Is(e, mvconfig.ErrMissingRequired).
+var (
+	// ErrMissingRequired means a `crit:"true"` field had no env, props or
+	// default value.
+	ErrMissingRequired = errors.New("critical config value missing")
+	// ErrParse means a value was found but couldn't be converted to the
+	// field's type, whether by the builtin kinds or a Decoder, or that its
+	// ${VAR} references couldn't be expanded.
+	ErrParse = errors.New("error parsing config value")
 )
 
+// AggregateError collects every field error found while loading a struct,
+// instead of returning on the first one, so a caller can see and fix all of
+// their missing/invalid variables in one pass.
+type AggregateError struct {
+	errs []error
+}
+
+// Errors returns every error that was collected, in field order.
+func (a *AggregateError) Errors() []error {
+	return a.errs
+}
+
+func (a *AggregateError) Error() string {
+	if len(a.errs) == 1 {
+		return a.errs[0].Error()
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d configuration errors occurred:\n", len(a.errs))
+	for _, e := range a.errs {
+		fmt.Fprintf(&sb, "\t* %s\n", e.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap exposes the collected errors to errors.Is and errors.As.
+func (a *AggregateError) Unwrap() []error {
+	return a.errs
+}
+
+// newAggregateError returns nil for an empty slice, the lone error unwrapped
+// for a single-element slice, or an *AggregateError otherwise - so callers
+// that only care about `err != nil` see exactly the same thing as before.
+func newAggregateError(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return &AggregateError{errs: errs}
+	}
+}
+
 type envTags struct {
 	Name       string
 	Critical   bool
 	HasDefault bool
 	Default    string
+	Sep        string
+	Expand     bool
+	Desc       string
+}
+
+// LoadOptions controls behaviour that applies to a whole Load call rather
+// than to a single field, such as how ${VAR} expansion handles a reference
+// that isn't set.
+type LoadOptions struct {
+	// ErrorOnUndefinedExpand makes expansion of an undefined ${VAR} or $VAR
+	// reference an error. When false (the default) it resolves to "".
+	ErrorOnUndefinedExpand bool
+}
+
+// DefaultOptions is used by all of the LoadVariables* helpers except
+// LoadVariablesWithOptions, which takes its own LoadOptions.
+var DefaultOptions = LoadOptions{ErrorOnUndefinedExpand: false}
+
+// Source is a place mvconfig can look up a named variable - the environment,
+// a properties file, JSON, a Vault/Consul client, or just a map in a test.
+// Sources are tried in order and the first one that has the name wins.
+type Source interface {
+	Lookup(name string) (string, bool)
+}
+
+// EnvSource looks variables up in the process environment via os.LookupEnv.
+type EnvSource struct{}
+
+func (EnvSource) Lookup(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+// PropsSource looks variables up in a magiconair/properties file. A nil
+// Props (e.g. because the file didn't exist) is treated as empty rather
+// than panicking, matching the old LoadVariables behaviour of ignoring a
+// missing properties file.
+type PropsSource struct {
+	Props *properties.Properties
+}
+
+// NewPropsSource loads fileName with properties.LoadFile, returning a source
+// that finds nothing if the file can't be loaded.
+func NewPropsSource(fileName string) PropsSource {
+	props, err := properties.LoadFile(fileName, properties.UTF8)
+	if err != nil {
+		props = nil
+	}
+	return PropsSource{Props: props}
+}
+
+func (p PropsSource) Lookup(name string) (string, bool) {
+	if p.Props == nil {
+		return "", false
+	}
+	return p.Props.Get(name)
+}
+
+// JSONSource looks variables up in a flat JSON object, e.g. {"PORT": 8080}.
+// Non-string values are formatted with fmt.Sprintf("%v", ...) so a field tag
+// still sees a plain string to parse.
+type JSONSource struct {
+	values map[string]string
+}
+
+// NewJSONSource parses data as a flat JSON object into a JSONSource.
+func NewJSONSource(data []byte) (JSONSource, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return JSONSource{}, fmt.Errorf("mvconfig: parsing JSON source: %w", err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		values[k] = fmt.Sprintf("%v", v)
+	}
+	return JSONSource{values: values}, nil
+}
+
+func (j JSONSource) Lookup(name string) (string, bool) {
+	value, ok := j.values[name]
+	return value, ok
+}
+
+// MapSource is an in-memory Source, mainly useful for tests.
+type MapSource map[string]string
+
+func (m MapSource) Lookup(name string) (string, bool) {
+	value, ok := m[name]
+	return value, ok
 }
 
 func LoadVariables(envStruct interface{}) error {
-	return loadVariables(envStruct, "", "app.properties")
+	return loadVariablesFromSources(envStruct, "", DefaultOptions, defaultSources("app.properties"))
 }
 
 func LoadVariablesWithProps(envStruct interface{}, fileName string) error {
-	return loadVariables(envStruct, "", fileName)
+	return loadVariablesFromSources(envStruct, "", DefaultOptions, defaultSources(fileName))
 }
 
 func LoadVariablesWithPrefix(envStruct interface{}, prefix string) error {
-	return loadVariables(envStruct, prefix, "app.properties")
+	return loadVariablesFromSources(envStruct, prefix, DefaultOptions, defaultSources("app.properties"))
 }
 
 func LoadVariablesWithPrefixAndProps(envStruct interface{}, prefix string, fileName string) error {
-	return loadVariables(envStruct, prefix, fileName)
+	return loadVariablesFromSources(envStruct, prefix, DefaultOptions, defaultSources(fileName))
 }
 
-func loadVariables(envStruct interface{}, prefix string, fileName string) error {
-	// Load the properties file
-	props, err := properties.LoadFile(fileName, properties.UTF8)
-	if err != nil {
-		props = nil
+// LoadVariablesWithOptions is like LoadVariablesWithPrefixAndProps but lets
+// you override LoadOptions, e.g. to make undefined expansion references an
+// error instead of resolving to "".
+func LoadVariablesWithOptions(envStruct interface{}, prefix string, fileName string, opts LoadOptions) error {
+	return loadVariablesFromSources(envStruct, prefix, opts, defaultSources(fileName))
+}
+
+// LoadVariablesFromSources loads envStruct from a caller-chosen chain of
+// Sources instead of the default [EnvSource, PropsSource] one, so you can
+// compose env, properties, JSON, a Vault/Consul client or a MapSource in
+// whatever precedence order you need.
+func LoadVariablesFromSources(envStruct interface{}, sources ...Source) error {
+	return loadVariablesFromSources(envStruct, "", DefaultOptions, sources)
+}
+
+// LoadVariablesFromSourcesWithPrefix is LoadVariablesFromSources with a
+// PREFIX applied to every variable name, matching LoadVariablesWithPrefix.
+func LoadVariablesFromSourcesWithPrefix(envStruct interface{}, prefix string, sources ...Source) error {
+	return loadVariablesFromSources(envStruct, prefix, DefaultOptions, sources)
+}
+
+// defaultSources builds the [EnvSource, PropsSource] chain every
+// LoadVariables* wrapper used before Source existed, preserving their
+// behaviour: env wins, then fileName, then field defaults.
+func defaultSources(fileName string) []Source {
+	return []Source{EnvSource{}, NewPropsSource(fileName)}
+}
+
+func loadVariablesFromSources(envStruct interface{}, prefix string, opts LoadOptions, sources []Source) error {
+	errs := manageFields(envStruct, sources, prefix, opts)
+	return newAggregateError(errs)
+}
+
+// Usage writes a tabular summary of envStruct's variables - name, type,
+// default, whether it's required and its `desc` tag - to w. Handy for a
+// `myapp -help-env` style dump so operators can see what to set without
+// reading the source.
+func Usage(envStruct interface{}, w io.Writer) error {
+	return Usagef(envStruct, w, "")
+}
+
+// Usagef is Usage with a PREFIX applied to the variable names, matching the
+// prefix you'd pass to LoadVariablesWithPrefix.
+func Usagef(envStruct interface{}, w io.Writer, prefix string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VARIABLE\tTYPE\tDEFAULT\tREQUIRED\tDESCRIPTION")
+
+	if err := usageFields(envStruct, tw, prefix); err != nil {
+		return err
 	}
+	return tw.Flush()
+}
 
-	err = manageFields(envStruct, props, prefix)
-	return err
+func usageFields(envVar interface{}, w *tabwriter.Writer, prefix string) error {
+	e := reflect.ValueOf(envVar)
+	if e.Kind() == reflect.Ptr {
+		e = e.Elem()
+	}
+	t := e.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+
+		eTags, err := getTags(t.Field(i))
+		if err != nil {
+			continue
+		}
+
+		// Unexported fields are never loaded, so they aren't listed either
+		if t.Field(i).PkgPath != "" {
+			continue
+		}
+
+		fld := e.Field(i)
+
+		subPrefix := eTags.Name
+		if prefix != "" {
+			subPrefix = prefix + "_" + eTags.Name
+		}
+
+		if isNestedStruct(fld.Type()) {
+			if err := usageFields(fld.Interface(), w, subPrefix); err != nil {
+				return err
+			}
+			continue
+		}
+
+		def := "-"
+		if eTags.HasDefault {
+			def = eTags.Default
+		}
+		required := "no"
+		if eTags.Critical {
+			required = "yes"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", subPrefix, fld.Type().String(), def, required, eTags.Desc)
+	}
+	return nil
 }
 
 /**
@@ -98,10 +378,23 @@ func getTags(f reflect.StructField) (envTags, error) {
 	e.Critical = false
 	e.HasDefault = false
 	e.Default = ""
+	e.Sep = ","
 
 	if value, ok := f.Tag.Lookup("mvenv"); ok {
 		e.Name = value
 	}
+	if sep, ok := f.Tag.Lookup("sep"); ok {
+		e.Sep = sep
+	}
+	if expand, ok := f.Tag.Lookup("expand"); ok {
+		expand = strings.ToLower(expand)
+		if expand == "true" || expand == "y" || expand == "t" {
+			e.Expand = true
+		}
+	}
+	if desc, ok := f.Tag.Lookup("desc"); ok {
+		e.Desc = desc
+	}
 	if crit, ok := f.Tag.Lookup("crit"); ok {
 		crit = strings.ToLower(crit)
 		if crit == "true" || crit == "y" || crit == "t" {
@@ -118,43 +411,68 @@ func getTags(f reflect.StructField) (envTags, error) {
 }
 
 /**
-Get the values from the environment, properties or default
+Get the values from the sources (in order) or the default
 */
-func getEnvValue(eTags envTags, props *properties.Properties, prefix string) (string, error, bool) {
+func getEnvValue(eTags envTags, sources []Source, prefix string) (string, error, bool) {
 
 	name := eTags.Name
 	if prefix != "" {
 		name = prefix + "_" + name
 	}
 
-	// Try from the environment variables first
-	if value, ok := os.LookupEnv(name); ok {
-		fmt.Println(name, value)
-		return value, nil, true
-	}
-
-	// If not in the environment - check the properties file
-	if props != nil {
-		if value, ok := props.Get(name); ok {
+	// Try each source in turn - the first one that has the name wins
+	for _, src := range sources {
+		if value, ok := src.Lookup(name); ok {
 			return value, nil, true
 		}
 	}
 
-	// If not in the properties - check the default value
+	// If not in any source - check the default value
 	if eTags.HasDefault {
 		return eTags.Default, nil, true
 	}
 
 	// If no default val - if its critical return an error
 	if eTags.Critical {
-		return "", errors.New("Critical Config field " + name + " missing from the environment"), false
+		return "", fmt.Errorf("%w: %s", ErrMissingRequired, name), false
 	}
 
 	// Otherwise - do nothing
 	return "", nil, false
 }
 
-func manageFields(envVar interface{}, props *properties.Properties, prefix string) error {
+// durationType is used to detect time.Duration fields, which reflect otherwise
+// reports as plain int64s.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+var (
+	decoderType         = reflect.TypeOf((*Decoder)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isNestedStruct reports whether t should be descended into as a sub-struct
+// (e.g. a DBConfig field on a Config) rather than treated as a leaf value.
+// time.Duration and types that decode themselves are leaves, not structs to
+// recurse into.
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	if t == durationType {
+		return false
+	}
+	return !t.Implements(decoderType) &&
+		!t.Implements(textUnmarshalerType) &&
+		!reflect.PtrTo(t).Implements(decoderType) &&
+		!reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// manageFields walks envVar's fields, collecting every error it hits along
+// the way rather than stopping at the first one, so callers can fix all of
+// their configuration in one pass.
+func manageFields(envVar interface{}, sources []Source, prefix string, opts LoadOptions) []error {
+
+	var errs []error
 
 	e := reflect.ValueOf(envVar).Elem()
 	t := e.Type()
@@ -162,41 +480,278 @@ func manageFields(envVar interface{}, props *properties.Properties, prefix strin
 	for i := 0; i < t.NumField(); i++ {
 
 		eTags, err := getTags(t.Field(i))
-		if err == nil {
-			// Need to lookup the field value
-			if value, err, ok := getEnvValue(eTags, props, prefix); ok {
-				fld := e.FieldByName(t.Field(i).Name)
-				if fld.CanSet() {
-					if e.Field(i).Kind() == reflect.String {
-						fld.SetString(value)
-					} else if e.Field(i).Kind() == reflect.Int ||
-						e.Field(i).Kind() == reflect.Int8 ||
-						e.Field(i).Kind() == reflect.Int32 ||
-						e.Field(i).Kind() == reflect.Int64 {
-						val, err := strconv.Atoi(value)
-						if err != nil {
-							return errors.New("Error converting field " + eTags.Name + " to int")
-						}
-						fld.SetInt(int64(val))
-					} else if e.Field(i).Kind() == reflect.Bool {
-						val, err := strconv.ParseBool(value)
-						if err != nil {
-							return errors.New("Error converting field " + eTags.Name + " to bool")
-						}
-						fld.SetBool(val)
-					} else if e.Field(i).Kind() == reflect.Float32 ||
-						e.Field(i).Kind() == reflect.Float32 {
-						val, err := strconv.ParseFloat(value, 64)
-						if err != nil {
-							return errors.New("Error converting field " + eTags.Name + " to float")
-						}
-						fld.SetFloat(val)
-					}
+		if err != nil {
+			continue
+		}
+
+		fld := e.FieldByName(t.Field(i).Name)
+		if !fld.CanSet() {
+			continue
+		}
+
+		subPrefix := eTags.Name
+		if prefix != "" {
+			subPrefix = prefix + "_" + eTags.Name
+		}
+
+		if isNestedStruct(fld.Type()) {
+			errs = append(errs, manageFields(fld.Addr().Interface(), sources, subPrefix, opts)...)
+			continue
+		}
+
+		// Need to lookup the field value
+		if value, err, ok := getEnvValue(eTags, sources, prefix); ok {
+			if eTags.Expand {
+				value, err = expandValue(value, opts)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%w: field %s: %v", ErrParse, eTags.Name, err))
+					continue
 				}
-			} else if err != nil {
-				return err
 			}
+			if err := setFieldValue(fld, eTags, value); err != nil {
+				errs = append(errs, err)
+			}
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// setFieldValue converts value into the kind of fld and assigns it, reporting
+// an error named after the field's tag name on failure. A field implementing
+// Decoder or encoding.TextUnmarshaler takes precedence over the builtin kinds.
+func setFieldValue(fld reflect.Value, eTags envTags, value string) error {
+	if ok, err := decodeField(fld, eTags, value); ok {
+		return err
+	}
+
+	if fld.Type() == durationType {
+		val, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%w: field %s to time.Duration", ErrParse, eTags.Name)
+		}
+		fld.SetInt(int64(val))
+		return nil
+	}
+
+	switch fld.Kind() {
+	case reflect.String:
+		fld.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%w: field %s to int", ErrParse, eTags.Name)
+		}
+		fld.SetInt(int64(val))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: field %s to uint", ErrParse, eTags.Name)
+		}
+		fld.SetUint(val)
+	case reflect.Bool:
+		val, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%w: field %s to bool", ErrParse, eTags.Name)
+		}
+		fld.SetBool(val)
+	case reflect.Float32, reflect.Float64:
+		val, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%w: field %s to float", ErrParse, eTags.Name)
+		}
+		fld.SetFloat(val)
+	case reflect.Slice:
+		return setSliceValue(fld, eTags, value)
+	case reflect.Map:
+		return setMapValue(fld, eTags, value)
+	default:
+		return fmt.Errorf("%w: unsupported field type %s for field %s", ErrParse, fld.Kind(), eTags.Name)
+	}
+	return nil
+}
+
+// decodeField looks for a Decoder or encoding.TextUnmarshaler implementation
+// on fld, trying the value receiver first and then, if fld is addressable,
+// the pointer receiver. The bool reports whether such an implementation was
+// found, so the caller knows whether to fall through to the builtin kinds.
+func decodeField(fld reflect.Value, eTags envTags, value string) (bool, error) {
+	candidates := []reflect.Value{fld}
+	if fld.CanAddr() {
+		candidates = append(candidates, fld.Addr())
+	}
+
+	// Decoder takes precedence over TextUnmarshaler, so every candidate is
+	// checked for one before either is checked for the other.
+	for _, candidate := range candidates {
+		if !candidate.CanInterface() {
+			continue
+		}
+		if dec, ok := candidate.Interface().(Decoder); ok {
+			if err := dec.Decode(value); err != nil {
+				return true, fmt.Errorf("%w: field %s: %v", ErrParse, eTags.Name, err)
+			}
+			return true, nil
+		}
+	}
+
+	for _, candidate := range candidates {
+		if !candidate.CanInterface() {
+			continue
+		}
+		if tu, ok := candidate.Interface().(encoding.TextUnmarshaler); ok {
+			if err := tu.UnmarshalText([]byte(value)); err != nil {
+				return true, fmt.Errorf("%w: field %s: %v", ErrParse, eTags.Name, err)
+			}
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// expandRefPattern matches both ${VAR} and bare $VAR style references.
+var expandRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// expandValue resolves ${VAR} and $VAR references in value against the
+// environment. Expansion is recursive - if VAR's own value contains further
+// references those are expanded too - guarded against cycles.
+func expandValue(value string, opts LoadOptions) (string, error) {
+	return expandValueGuarded(value, opts, map[string]bool{})
+}
+
+func expandValueGuarded(value string, opts LoadOptions, seen map[string]bool) (string, error) {
+	var expandErr error
+
+	result := expandRefPattern.ReplaceAllStringFunc(value, func(match string) string {
+		if expandErr != nil {
+			return match
+		}
+
+		groups := expandRefPattern.FindStringSubmatch(match)
+		name := groups[1]
+		if name == "" {
+			name = groups[2]
+		}
+
+		if seen[name] {
+			expandErr = errors.New("cyclic variable expansion detected for " + name)
+			return match
+		}
+
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			if opts.ErrorOnUndefinedExpand {
+				expandErr = errors.New("undefined variable reference " + name + " in expansion")
+			}
+			return ""
 		}
+
+		nestedSeen := make(map[string]bool, len(seen)+1)
+		for k := range seen {
+			nestedSeen[k] = true
+		}
+		nestedSeen[name] = true
+
+		expanded, err := expandValueGuarded(raw, opts, nestedSeen)
+		if err != nil {
+			expandErr = err
+			return match
+		}
+		return expanded
+	})
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return result, nil
+}
+
+// setSliceValue splits value on eTags.Sep and populates a []string or []int field.
+func setSliceValue(fld reflect.Value, eTags envTags, value string) error {
+	tokens := splitNonEmpty(value, eTags.Sep)
+
+	switch fld.Type().Elem().Kind() {
+	case reflect.String:
+		slice := make([]string, len(tokens))
+		copy(slice, tokens)
+		fld.Set(reflect.ValueOf(slice))
+	case reflect.Int:
+		slice := make([]int, len(tokens))
+		for i, tok := range tokens {
+			val, err := strconv.Atoi(strings.TrimSpace(tok))
+			if err != nil {
+				return fmt.Errorf("%w: field %s to []int", ErrParse, eTags.Name)
+			}
+			slice[i] = val
+		}
+		fld.Set(reflect.ValueOf(slice))
+	default:
+		return fmt.Errorf("%w: unsupported slice element type for field %s", ErrParse, eTags.Name)
 	}
 	return nil
 }
+
+// setMapValue parses a "k:v,k:v" style value into a map[string]string or
+// map[string]int field, splitting pairs on eTags.Sep and each pair on ":".
+func setMapValue(fld reflect.Value, eTags envTags, value string) error {
+	if fld.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("%w: unsupported map key type for field %s", ErrParse, eTags.Name)
+	}
+
+	pairs := splitNonEmpty(value, eTags.Sep)
+
+	switch fld.Type().Elem().Kind() {
+	case reflect.String:
+		m := make(map[string]string, len(pairs))
+		for _, pair := range pairs {
+			k, v, err := splitPair(pair)
+			if err != nil {
+				return fmt.Errorf("%w: field %s to map[string]string", ErrParse, eTags.Name)
+			}
+			m[k] = v
+		}
+		fld.Set(reflect.ValueOf(m))
+	case reflect.Int:
+		m := make(map[string]int, len(pairs))
+		for _, pair := range pairs {
+			k, v, err := splitPair(pair)
+			if err != nil {
+				return fmt.Errorf("%w: field %s to map[string]int", ErrParse, eTags.Name)
+			}
+			val, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("%w: field %s to map[string]int", ErrParse, eTags.Name)
+			}
+			m[k] = val
+		}
+		fld.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("%w: unsupported map value type for field %s", ErrParse, eTags.Name)
+	}
+	return nil
+}
+
+// splitNonEmpty splits value on sep, trims whitespace and drops empty tokens,
+// so a trailing separator or blank value doesn't produce a spurious entry.
+func splitNonEmpty(value string, sep string) []string {
+	raw := strings.Split(value, sep)
+	tokens := make([]string, 0, len(raw))
+	for _, tok := range raw {
+		tok = strings.TrimSpace(tok)
+		if tok != "" {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+// splitPair splits a single "k:v" token into its key and value.
+func splitPair(pair string) (string, string, error) {
+	parts := strings.SplitN(pair, ":", 2)
+	if len(parts) != 2 {
+		return "", "", errors.New("invalid key:value pair " + pair)
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
+}